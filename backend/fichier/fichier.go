@@ -298,28 +298,29 @@ func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options .
 // This will create a duplicate if we upload a new file without
 // checking to see if there is one already - use Put() for that.
 func (f *Fs) putUnchecked(ctx context.Context, in io.Reader, remote string, size int64, options ...fs.OpenOption) (fs.Object, error) {
-	if size > int64(100E9) {
+	if size > int64(100e9) {
 		return nil, errors.New("File too big, cant upload")
-	} else if size == 0 {
+	}
+	if size == 0 {
 		return nil, fs.ErrorCantUploadEmptyFiles
 	}
 
-	nodeResponse, err := f.getUploadNode()
+	leaf, directoryID, err := f.dirCache.FindRootAndPath(ctx, remote, true)
 	if err != nil {
 		return nil, err
 	}
 
-	leaf, directoryID, err := f.dirCache.FindRootAndPath(ctx, remote, true)
+	nodeResponse, err := f.getUploadNode()
 	if err != nil {
 		return nil, err
 	}
-
-	_, err = f.uploadFile(in, size, leaf, directoryID, nodeResponse.ID, nodeResponse.URL)
+	nodeID, nodeURL := nodeResponse.ID, nodeResponse.URL
+	_, err = f.uploadFile(in, size, leaf, directoryID, nodeID, nodeURL)
 	if err != nil {
 		return nil, err
 	}
 
-	fileUploadResponse, err := f.endUpload(nodeResponse.ID, nodeResponse.URL)
+	fileUploadResponse, err := f.endUpload(nodeID, nodeURL)
 	if err != nil {
 		return nil, err
 	}
@@ -6,9 +6,12 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ncw/rclone/cmd/serve/httplib"
 	"github.com/ncw/rclone/cmd/serve/httplib/serve"
@@ -36,8 +39,10 @@ func Start(opt *rc.Options) (*Server, error) {
 // Server contains everything to run the rc server
 type Server struct {
 	*httplib.Server
-	files http.Handler
-	opt   *rc.Options
+	files       http.Handler
+	filesPath   string
+	spaFallback bool
+	opt         *rc.Options
 }
 
 func newServer(opt *rc.Options, mux *http.ServeMux) *Server {
@@ -55,6 +60,13 @@ func newServer(opt *rc.Options, mux *http.ServeMux) *Server {
 	if opt.Files != "" {
 		fs.Logf(nil, "Serving files from %q", opt.Files)
 		s.files = http.FileServer(http.Dir(opt.Files))
+		s.filesPath = opt.Files
+		// If there is an index.html in the root of the served files then
+		// fall back to it for paths which don't exist on disk, so that
+		// single-page web apps using client-side routing keep working.
+		if _, err := os.Stat(filepath.Join(opt.Files, "index.html")); err == nil {
+			s.spaFallback = true
+		}
 	}
 	return s
 }
@@ -134,6 +146,14 @@ func (s *Server) handler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string) {
 	contentType := r.Header.Get("Content-Type")
 
+	// operations/uploadfile streams the request body straight into the
+	// target Fs rather than going through the generic form/JSON parsing
+	// below, so it needs to be intercepted before the body is touched.
+	if path == "operations/uploadfile" && isUploadFileContentType(contentType) {
+		s.handleUploadFile(w, r, path)
+		return
+	}
+
 	values := r.URL.Query()
 	if contentType == "application/x-www-form-urlencoded" {
 		// Parse the POST and URL parameters into r.Form, for others r.Form will be empty value
@@ -272,7 +292,7 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, path string)
 		return
 	case s.files != nil:
 		// Serve the files
-		s.files.ServeHTTP(w, r)
+		s.serveFiles(w, r, path)
 		return
 	case path == "" && s.opt.Serve:
 		// Serve the root as a remote listing
@@ -281,3 +301,28 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, path string)
 	}
 	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 }
+
+// serveFiles serves static files from s.filesPath.  If the requested
+// path doesn't exist on disk and a root index.html was found at
+// startup, it serves that instead of a 404 so that single-page web
+// apps using client-side routing continue to work.  If index.html
+// can't be read after all, it falls back to the normal 404 behaviour
+// of the file server.
+func (s *Server) serveFiles(w http.ResponseWriter, r *http.Request, path string) {
+	if s.spaFallback {
+		localPath := filepath.Join(s.filesPath, filepath.FromSlash(path))
+		if _, err := os.Stat(localPath); os.IsNotExist(err) {
+			indexPath := filepath.Join(s.filesPath, "index.html")
+			in, err := os.Open(indexPath)
+			if err == nil {
+				defer func() {
+					_ = in.Close()
+				}()
+				http.ServeContent(w, r, "index.html", time.Time{}, in)
+				return
+			}
+			fs.Errorf(nil, "rc: failed to open index.html for SPA fallback: %v", err)
+		}
+	}
+	s.files.ServeHTTP(w, r)
+}
@@ -0,0 +1,90 @@
+package rcserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeFilesSPAFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rcserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	const indexContent = "<html>spa index</html>"
+	err = ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(indexContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		files:       http.FileServer(http.Dir(dir)),
+		filesPath:   dir,
+		spaFallback: true,
+	}
+
+	req := httptest.NewRequest("GET", "/some/client/side/route", nil)
+	w := httptest.NewRecorder()
+	s.serveFiles(w, req, "some/client/side/route")
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != indexContent {
+		t.Fatalf("expected index.html content %q, got %q", indexContent, body)
+	}
+}
+
+func TestServeFilesExistingFileIsNotOverridden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rcserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	err = ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("index"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const fileContent = "a real file"
+	err = ioutil.WriteFile(filepath.Join(dir, "real.txt"), []byte(fileContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		files:       http.FileServer(http.Dir(dir)),
+		filesPath:   dir,
+		spaFallback: true,
+	}
+
+	req := httptest.NewRequest("GET", "/real.txt", nil)
+	w := httptest.NewRecorder()
+	s.serveFiles(w, req, "real.txt")
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != fileContent {
+		t.Fatalf("expected real file content %q, got %q", fileContent, body)
+	}
+}
@@ -0,0 +1,41 @@
+package rcserver
+
+import "testing"
+
+// handleUploadFile resolves its target Fs via fs/cache before it ever looks
+// at the multipart body, so a full request round-trip needs a registered
+// backend and config - neither of which exist in this source tree. The
+// part of handleUploadFile that is self-contained and security sensitive is
+// sanitizeUploadFilename, so that's what's covered here.
+func TestSanitizeUploadFilename(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		wantErr  bool
+	}{
+		{"file.txt", false},
+		{"report 2020.csv", false},
+		{".hidden", false},
+		{"", false},
+		{".", true},
+		{"..", true},
+		{"../escape.txt", true},
+		{"../../etc/passwd", true},
+		{"dir/file.txt", true},
+		{"dir\\file.txt", true},
+	} {
+		got, err := sanitizeUploadFilename(test.filename)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("sanitizeUploadFilename(%q): expected error, got none", test.filename)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("sanitizeUploadFilename(%q): unexpected error: %v", test.filename, err)
+			continue
+		}
+		if got != test.filename {
+			t.Errorf("sanitizeUploadFilename(%q) = %q, want %q", test.filename, got, test.filename)
+		}
+	}
+}